@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// selfMetricsType / evictedCounterKey name the self-metric that records how
+// many (typ, labels) entries the cardinality sweeper has evicted, so
+// operators can detect label explosions from dynamic upstreams.
+const (
+	selfMetricsType   = "mosn.metrics"
+	evictedCounterKey = "evicted"
+
+	cardinalitySweepInterval = 30 * time.Second
+)
+
+var (
+	cardinalityMutex sync.Mutex
+	maxEntries       int
+	maxIdle          time.Duration
+	sweeperStop      chan struct{}
+)
+
+// SetCardinalityLimits bounds the size of defaultStore: entries whose
+// Counter/Gauge/Histogram has not been accessed for longer than maxIdle are
+// evicted, and once the store holds more than maxEntries entries the least
+// recently used ones are evicted until it fits. A zero/negative value
+// disables that particular limit.
+func SetCardinalityLimits(maxEntriesLimit int, maxIdleLimit time.Duration) {
+	cardinalityMutex.Lock()
+	defer cardinalityMutex.Unlock()
+
+	maxEntries = maxEntriesLimit
+	maxIdle = maxIdleLimit
+
+	if sweeperStop != nil {
+		close(sweeperStop)
+	}
+	stop := make(chan struct{})
+	sweeperStop = stop
+
+	go runSweeper(stop)
+}
+
+func runSweeper(stop chan struct{}) {
+	ticker := time.NewTicker(cardinalitySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sweep()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sweep evicts idle and, if over capacity, least-recently-used entries from
+// defaultStore. Eviction unregisters the underlying gometrics registry so
+// its goroutine-registered samples are released. The selfMetricsType entry
+// is exempt, since it records the sweeper's own eviction count and must
+// survive the sweep it implements.
+func sweep() {
+	cardinalityMutex.Lock()
+	idleLimit := maxIdle
+	entryLimit := maxEntries
+	cardinalityMutex.Unlock()
+
+	if idleLimit <= 0 && entryLimit <= 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+
+	defaultStore.mutex.Lock()
+	var evicted []types.Metrics
+
+	if idleLimit > 0 {
+		for name, m := range defaultStore.metrics {
+			if m.Type() == selfMetricsType {
+				continue
+			}
+			ms, ok := m.(*metrics)
+			if !ok {
+				continue
+			}
+			if time.Duration(now-atomic.LoadInt64(&ms.lastAccess)) > idleLimit {
+				evicted = append(evicted, m)
+				delete(defaultStore.metrics, name)
+			}
+		}
+	}
+
+	if entryLimit > 0 && len(defaultStore.metrics) > entryLimit {
+		type candidate struct {
+			name       string
+			lastAccess int64
+		}
+		candidates := make([]candidate, 0, len(defaultStore.metrics))
+		for name, m := range defaultStore.metrics {
+			if m.Type() == selfMetricsType {
+				continue
+			}
+			if ms, ok := m.(*metrics); ok {
+				candidates = append(candidates, candidate{name, atomic.LoadInt64(&ms.lastAccess)})
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].lastAccess < candidates[j].lastAccess })
+
+		toEvict := len(defaultStore.metrics) - entryLimit
+		for i := 0; i < toEvict && i < len(candidates); i++ {
+			name := candidates[i].name
+			evicted = append(evicted, defaultStore.metrics[name])
+			delete(defaultStore.metrics, name)
+		}
+	}
+	defaultStore.mutex.Unlock()
+
+	for _, m := range evicted {
+		m.UnregisterAll()
+	}
+	if len(evicted) > 0 {
+		reportEvicted(len(evicted))
+	}
+}
+
+// reportEvicted records the eviction count on the mosn.metrics.evicted
+// self-metric. It is called outside of defaultStore.mutex since NewMetrics
+// acquires that same lock.
+func reportEvicted(n int) {
+	self, err := NewMetrics(selfMetricsType, nil)
+	if err != nil {
+		return
+	}
+	self.Counter(evictedCounterKey).Inc(int64(n))
+}
@@ -0,0 +1,216 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// touchAt backdates m's lastAccess so the sweeper sees it as idle/LRU.
+func touchAt(m *metrics, when time.Time) {
+	atomic.StoreInt64(&m.lastAccess, when.UnixNano())
+}
+
+func resetCardinalityLimits() {
+	cardinalityMutex.Lock()
+	maxEntries = 0
+	maxIdle = 0
+	cardinalityMutex.Unlock()
+}
+
+func TestSweepEvictsIdleEntries(t *testing.T) {
+	ResetAll()
+	defer func() {
+		ResetAll()
+		resetCardinalityLimits()
+	}()
+
+	fresh, err := NewMetrics("test_idle_fresh", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	stale, err := NewMetrics("test_idle_stale", map[string]string{"id": "2"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	touchAt(stale.(*metrics), time.Now().Add(-time.Hour))
+
+	cardinalityMutex.Lock()
+	maxIdle = time.Minute
+	maxEntries = 0
+	cardinalityMutex.Unlock()
+
+	sweep()
+
+	all := GetAll()
+	for _, m := range all {
+		if m.Type() == "test_idle_stale" {
+			t.Fatal("expected the idle entry to be evicted")
+		}
+	}
+	foundFresh := false
+	for _, m := range all {
+		if m.Type() == "test_idle_fresh" {
+			foundFresh = true
+		}
+	}
+	if !foundFresh {
+		t.Fatal("expected the recently-touched entry to survive")
+	}
+	_ = fresh
+}
+
+func TestSweepEvictsLeastRecentlyUsedOverCapacity(t *testing.T) {
+	ResetAll()
+	defer func() {
+		ResetAll()
+		resetCardinalityLimits()
+	}()
+
+	oldest, err := NewMetrics("test_lru_a", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	middle, err := NewMetrics("test_lru_b", map[string]string{"id": "2"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	newest, err := NewMetrics("test_lru_c", map[string]string{"id": "3"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+
+	now := time.Now()
+	touchAt(oldest.(*metrics), now.Add(-3*time.Minute))
+	touchAt(middle.(*metrics), now.Add(-2*time.Minute))
+	touchAt(newest.(*metrics), now.Add(-1*time.Minute))
+
+	cardinalityMutex.Lock()
+	maxIdle = 0
+	maxEntries = 2
+	cardinalityMutex.Unlock()
+
+	sweep()
+
+	remaining := map[string]bool{}
+	for _, m := range GetAll() {
+		remaining[m.Type()] = true
+	}
+	if remaining["test_lru_a"] {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+	if !remaining["test_lru_b"] || !remaining["test_lru_c"] {
+		t.Fatal("expected the more recently used entries to survive")
+	}
+}
+
+func TestSweepReportsEvictedSelfMetric(t *testing.T) {
+	ResetAll()
+	defer func() {
+		ResetAll()
+		resetCardinalityLimits()
+	}()
+
+	stale, err := NewMetrics("test_evicted_counter", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	touchAt(stale.(*metrics), time.Now().Add(-time.Hour))
+
+	cardinalityMutex.Lock()
+	maxIdle = time.Minute
+	maxEntries = 0
+	cardinalityMutex.Unlock()
+
+	sweep()
+
+	var evictedCount int64 = -1
+	for _, m := range GetAll() {
+		if m.Type() != selfMetricsType {
+			continue
+		}
+		m.Each(func(key string, i interface{}) {
+			if key != evictedCounterKey {
+				return
+			}
+			if c, ok := i.(interface{ Count() int64 }); ok {
+				evictedCount = c.Count()
+			}
+		})
+	}
+	if evictedCount != 1 {
+		t.Fatalf("expected mosn.metrics.evicted counter to be 1, got %d", evictedCount)
+	}
+}
+
+// TestSweepExemptsSelfMetricFromIdleEviction guards against the evicted
+// counter itself going idle and being swept away: if it were evicted like
+// any other entry, the next reportEvicted call would recreate it from zero,
+// silently resetting the cumulative count an operator might be alerting on.
+func TestSweepExemptsSelfMetricFromIdleEviction(t *testing.T) {
+	ResetAll()
+	defer func() {
+		ResetAll()
+		resetCardinalityLimits()
+	}()
+
+	cardinalityMutex.Lock()
+	maxIdle = time.Minute
+	maxEntries = 0
+	cardinalityMutex.Unlock()
+
+	stale, err := NewMetrics("test_evicted_counter_idle", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	touchAt(stale.(*metrics), time.Now().Add(-time.Hour))
+	sweep()
+
+	self, err := NewMetrics(selfMetricsType, nil)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	touchAt(self.(*metrics), time.Now().Add(-time.Hour))
+
+	sweep()
+
+	found := false
+	var evictedCount int64 = -1
+	for _, m := range GetAll() {
+		if m.Type() != selfMetricsType {
+			continue
+		}
+		found = true
+		m.Each(func(key string, i interface{}) {
+			if key != evictedCounterKey {
+				return
+			}
+			if c, ok := i.(interface{ Count() int64 }); ok {
+				evictedCount = c.Count()
+			}
+		})
+	}
+	if !found {
+		t.Fatal("expected the self-metric to survive an idle sweep")
+	}
+	if evictedCount != 1 {
+		t.Fatalf("expected mosn.metrics.evicted counter to remain 1 across the idle sweep, got %d", evictedCount)
+	}
+}
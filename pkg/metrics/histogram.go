@@ -0,0 +1,205 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// HistogramKind selects the sampling strategy backing a Histogram.
+type HistogramKind int
+
+const (
+	// HistogramExpDecay uses an exponentially-decaying sample (Vitter's
+	// Algorithm R with a forward decay), favoring recent observations over
+	// old ones. This is the default.
+	HistogramExpDecay HistogramKind = iota
+	// HistogramHDR uses an HDR histogram, trading memory for bounded-error
+	// percentiles across the full observed range.
+	HistogramHDR
+	// HistogramUniform keeps the original NewUniformSample(100) behavior.
+	HistogramUniform
+)
+
+// HistogramConfig selects the HistogramKind used for a metrics type.
+type HistogramConfig struct {
+	Kind HistogramKind
+}
+
+// HistogramPercentiles are the percentiles exposed by Histogram.Percentiles
+// for downstream sinks (see HistogramPercentileNames for matching labels).
+var HistogramPercentiles = []float64{0.5, 0.9, 0.99, 0.999}
+
+// HistogramPercentileNames label HistogramPercentiles positionally: p50, p90, p99, p999.
+var HistogramPercentileNames = []string{"p50", "p90", "p99", "p999"}
+
+const (
+	expDecaySampleSize    = 1028
+	expDecaySampleAlpha   = 0.015
+	uniformSampleSize     = 100
+	hdrHistogramMaxValue  = 3600000000 // 1 hour expressed in microseconds, a generous upper bound for latencies
+	hdrHistogramSigDigits = 3
+)
+
+var (
+	histogramConfigMutex sync.RWMutex
+	histogramConfigs     = map[string]HistogramConfig{}
+)
+
+// RegisterHistogramConfig sets the histogram sampling strategy used for typ.
+// It must be called before NewMetrics(typ, ...) records the first Histogram
+// sample of that type; the sample is chosen once when the Histogram is
+// lazily created and is not swapped afterwards.
+func RegisterHistogramConfig(typ string, cfg HistogramConfig) {
+	histogramConfigMutex.Lock()
+	defer histogramConfigMutex.Unlock()
+	histogramConfigs[typ] = cfg
+}
+
+func histogramConfigFor(typ string) HistogramConfig {
+	histogramConfigMutex.RLock()
+	defer histogramConfigMutex.RUnlock()
+	if cfg, ok := histogramConfigs[typ]; ok {
+		return cfg
+	}
+	return HistogramConfig{Kind: HistogramExpDecay}
+}
+
+func newSample(kind HistogramKind) gometrics.Sample {
+	switch kind {
+	case HistogramHDR:
+		return newHDRSample()
+	case HistogramUniform:
+		return gometrics.NewUniformSample(uniformSampleSize)
+	default:
+		return gometrics.NewExpDecaySample(expDecaySampleSize, expDecaySampleAlpha)
+	}
+}
+
+// hdrSample adapts a hdrhistogram.Histogram to gometrics.Sample so it can be
+// passed to gometrics.NewHistogram like any other sample implementation.
+type hdrSample struct {
+	mutex sync.Mutex
+	hist  *hdrhistogram.Histogram
+}
+
+func newHDRSample() *hdrSample {
+	return &hdrSample{
+		hist: hdrhistogram.New(0, hdrHistogramMaxValue, hdrHistogramSigDigits),
+	}
+}
+
+func (h *hdrSample) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.hist.Reset()
+}
+
+func (h *hdrSample) Count() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.hist.TotalCount()
+}
+
+func (h *hdrSample) Max() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.hist.Max()
+}
+
+func (h *hdrSample) Mean() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.hist.Mean()
+}
+
+func (h *hdrSample) Min() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.hist.Min()
+}
+
+func (h *hdrSample) Percentile(p float64) float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return float64(h.hist.ValueAtQuantile(p * 100))
+}
+
+func (h *hdrSample) Percentiles(ps []float64) []float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	out := make([]float64, len(ps))
+	for i, p := range ps {
+		out[i] = float64(h.hist.ValueAtQuantile(p * 100))
+	}
+	return out
+}
+
+func (h *hdrSample) Size() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return int(h.hist.TotalCount())
+}
+
+func (h *hdrSample) Snapshot() gometrics.Sample {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return &hdrSample{hist: hdrhistogram.Import(h.hist.Export())}
+}
+
+func (h *hdrSample) StdDev() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.hist.StdDev()
+}
+
+func (h *hdrSample) Sum() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var sum int64
+	for _, b := range h.hist.Distribution() {
+		sum += b.Count * ((b.From + b.To) / 2)
+	}
+	return sum
+}
+
+func (h *hdrSample) Update(v int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.hist.RecordValue(v)
+}
+
+func (h *hdrSample) Values() []int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var values []int64
+	for _, b := range h.hist.Distribution() {
+		for i := int64(0); i < b.Count; i++ {
+			values = append(values, (b.From+b.To)/2)
+		}
+	}
+	return values
+}
+
+func (h *hdrSample) Variance() float64 {
+	stdDev := h.StdDev()
+	return stdDev * stdDev
+}
@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// hdrSample must satisfy gometrics.Sample so it can be handed to
+// gometrics.NewHistogram like any other sample implementation.
+var _ gometrics.Sample = (*hdrSample)(nil)
+
+func TestNewSampleSelectsByKind(t *testing.T) {
+	if _, ok := newSample(HistogramExpDecay).(*hdrSample); ok {
+		t.Fatal("HistogramExpDecay must not produce an hdrSample")
+	}
+	if _, ok := newSample(HistogramHDR).(*hdrSample); !ok {
+		t.Fatal("HistogramHDR must produce an hdrSample")
+	}
+}
+
+func TestHistogramConfigRegistration(t *testing.T) {
+	const typ = "test_histogram_config"
+
+	if got := histogramConfigFor(typ).Kind; got != HistogramExpDecay {
+		t.Fatalf("expected default kind ExpDecay, got %v", got)
+	}
+
+	RegisterHistogramConfig(typ, HistogramConfig{Kind: HistogramHDR})
+	if got := histogramConfigFor(typ).Kind; got != HistogramHDR {
+		t.Fatalf("expected registered kind HDR, got %v", got)
+	}
+}
+
+func TestHDRSamplePercentiles(t *testing.T) {
+	s := newHDRSample()
+	for i := int64(1); i <= 100; i++ {
+		s.Update(i)
+	}
+
+	if got := s.Count(); got != 100 {
+		t.Fatalf("expected count 100, got %d", got)
+	}
+	if got := s.Min(); got != 1 {
+		t.Fatalf("expected min 1, got %d", got)
+	}
+	if got := s.Max(); got != 100 {
+		t.Fatalf("expected max 100, got %d", got)
+	}
+
+	median := s.Percentile(0.5)
+	if median < 45 || median > 55 {
+		t.Fatalf("expected p50 near 50, got %v", median)
+	}
+
+	pcts := s.Percentiles(HistogramPercentiles)
+	if len(pcts) != len(HistogramPercentiles) {
+		t.Fatalf("expected %d percentiles, got %d", len(HistogramPercentiles), len(pcts))
+	}
+	for i := 1; i < len(pcts); i++ {
+		if pcts[i] < pcts[i-1] {
+			t.Fatalf("percentiles must be non-decreasing, got %v", pcts)
+		}
+	}
+}
+
+func TestHistogramExposesPercentilesThroughEach(t *testing.T) {
+	ResetAll()
+	defer ResetAll()
+
+	RegisterHistogramConfig("test_histogram_each", HistogramConfig{Kind: HistogramHDR})
+
+	m, err := NewMetrics("test_histogram_each", nil)
+	if err != nil {
+		t.Fatalf("NewMetrics returned error: %v", err)
+	}
+	h := m.Histogram("latency")
+	for i := int64(1); i <= 10; i++ {
+		h.Update(i * 10)
+	}
+
+	found := false
+	m.Each(func(key string, i interface{}) {
+		if key != "latency" {
+			return
+		}
+		hist, ok := i.(gometrics.Histogram)
+		if !ok {
+			t.Fatalf("expected a gometrics.Histogram for key %q", key)
+		}
+		found = true
+		if hist.Count() != 10 {
+			t.Fatalf("expected count 10, got %d", hist.Count())
+		}
+		pcts := hist.Percentiles(HistogramPercentiles)
+		if len(pcts) != len(HistogramPercentiles) {
+			t.Fatalf("expected %d percentiles, got %d", len(HistogramPercentiles), len(pcts))
+		}
+		if pcts[0] < 45 || pcts[0] > 55 {
+			t.Fatalf("expected p50 near 50, got %v", pcts[0])
+		}
+	})
+	if !found {
+		t.Fatal("expected to find the latency histogram via Each")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+// MatcherMode selects whether a StatsMatcherSpec keeps only what matches
+// (allow-list) or drops what matches (reject-list, the historical behavior).
+type MatcherMode int
+
+const (
+	// MatcherReject drops metrics/labels that match any pattern. This is the
+	// behavior the original exclusionLabels list implemented.
+	MatcherReject MatcherMode = iota
+	// MatcherAllow keeps only metrics/labels that match at least one pattern.
+	MatcherAllow
+)
+
+// PatternKind selects how Pattern.Value is interpreted.
+type PatternKind int
+
+const (
+	// PatternGlob matches using shell glob syntax, e.g. "upstream_*".
+	PatternGlob PatternKind = iota
+	// PatternRegex matches using RE2 regular expression syntax.
+	PatternRegex
+)
+
+// Pattern is a single glob/regex rule.
+type Pattern struct {
+	Kind  PatternKind
+	Value string
+}
+
+// StatsMatcherSpec configures which metrics types and labels are kept or
+// dropped by the store. An empty Type/LabelKeys/LabelValues list means "no
+// constraint on this dimension".
+type StatsMatcherSpec struct {
+	Mode MatcherMode
+	// Type patterns are evaluated against a metrics' Type().
+	Type []Pattern
+	// LabelKeys patterns are evaluated against each label key.
+	LabelKeys []Pattern
+	// LabelValues patterns are evaluated against each label value.
+	LabelValues []Pattern
+}
+
+// compiledPattern is a Pattern that has already been parsed into a matchable form.
+type compiledPattern struct {
+	isRegex bool
+	glob    string
+	regex   *regexp.Regexp
+}
+
+func compilePattern(p Pattern) compiledPattern {
+	if p.Kind == PatternRegex {
+		re, err := regexp.Compile(p.Value)
+		if err != nil {
+			// an invalid regex must never silently match everything;
+			// log.DefaultLogger belongs at call sites, so here we fall back
+			// to a regex that matches nothing, not even the empty string.
+			return compiledPattern{isRegex: true, regex: regexp.MustCompile(`[^\x00-\x{10FFFF}]`)}
+		}
+		return compiledPattern{isRegex: true, regex: re}
+	}
+	return compiledPattern{glob: p.Value}
+}
+
+func (c compiledPattern) match(s string) bool {
+	if c.isRegex {
+		return c.regex.MatchString(s)
+	}
+	ok, err := filepath.Match(c.glob, s)
+	return err == nil && ok
+}
+
+// compiledMatcher is the evaluated form of a StatsMatcherSpec, cached on the
+// store so NewMetrics does not recompile patterns on every call.
+type compiledMatcher struct {
+	mode        MatcherMode
+	typ         []compiledPattern
+	labelKeys   []compiledPattern
+	labelValues []compiledPattern
+}
+
+func compileMatcher(spec *StatsMatcherSpec) *compiledMatcher {
+	if spec == nil {
+		return nil
+	}
+	m := &compiledMatcher{mode: spec.Mode}
+	for _, p := range spec.Type {
+		m.typ = append(m.typ, compilePattern(p))
+	}
+	for _, p := range spec.LabelKeys {
+		m.labelKeys = append(m.labelKeys, compilePattern(p))
+	}
+	for _, p := range spec.LabelValues {
+		m.labelValues = append(m.labelValues, compilePattern(p))
+	}
+	return m
+}
+
+func matchAny(patterns []compiledPattern, s string) bool {
+	for _, p := range patterns {
+		if p.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow reports whether typ/labels should be kept, and returns the label map
+// that should actually be attached to the metrics (labels may be stripped
+// individually even when the metric itself is kept).
+func (m *compiledMatcher) allow(typ string, labels map[string]string) (bool, map[string]string) {
+	typeMatched := matchAny(m.typ, typ)
+	switch m.mode {
+	case MatcherAllow:
+		if len(m.typ) > 0 && !typeMatched {
+			return false, nil
+		}
+	default: // MatcherReject
+		if typeMatched {
+			return false, nil
+		}
+	}
+
+	if len(m.labelKeys) == 0 && len(m.labelValues) == 0 {
+		return true, labels
+	}
+
+	filtered := make(map[string]string, len(labels))
+	for k, v := range labels {
+		matched := matchAny(m.labelKeys, k) || matchAny(m.labelValues, v)
+		switch m.mode {
+		case MatcherAllow:
+			if matched {
+				filtered[k] = v
+			}
+		default: // MatcherReject
+			if !matched {
+				filtered[k] = v
+			}
+		}
+	}
+	return true, filtered
+}
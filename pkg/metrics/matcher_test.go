@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "testing"
+
+func TestCompileMatcherNilSpec(t *testing.T) {
+	if compileMatcher(nil) != nil {
+		t.Fatal("compileMatcher(nil) should return a nil matcher")
+	}
+}
+
+func TestMatcherRejectByType(t *testing.T) {
+	spec := &StatsMatcherSpec{
+		Mode: MatcherReject,
+		Type: []Pattern{{Kind: PatternGlob, Value: "upstream_*"}},
+	}
+	m := compileMatcher(spec)
+
+	allowed, _ := m.allow("upstream_rt", map[string]string{"cluster": "foo"})
+	if allowed {
+		t.Fatal("expected upstream_rt to be rejected")
+	}
+
+	allowed, labels := m.allow("downstream_rt", map[string]string{"cluster": "foo"})
+	if !allowed {
+		t.Fatal("expected downstream_rt to be allowed")
+	}
+	if labels["cluster"] != "foo" {
+		t.Fatalf("expected labels to pass through unchanged, got %v", labels)
+	}
+}
+
+func TestMatcherAllowByTypeRegex(t *testing.T) {
+	spec := &StatsMatcherSpec{
+		Mode: MatcherAllow,
+		Type: []Pattern{{Kind: PatternRegex, Value: `^upstream_.+`}},
+	}
+	m := compileMatcher(spec)
+
+	if allowed, _ := m.allow("upstream_rt", nil); !allowed {
+		t.Fatal("expected upstream_rt to be allowed")
+	}
+	if allowed, _ := m.allow("downstream_rt", nil); allowed {
+		t.Fatal("expected downstream_rt to be rejected in allow-list mode")
+	}
+}
+
+func TestMatcherStripsLabelsInRejectMode(t *testing.T) {
+	spec := &StatsMatcherSpec{
+		Mode:      MatcherReject,
+		LabelKeys: []Pattern{{Kind: PatternGlob, Value: "request_id"}},
+	}
+	m := compileMatcher(spec)
+
+	allowed, labels := m.allow("upstream_rt", map[string]string{
+		"cluster":    "foo",
+		"request_id": "abc-123",
+	})
+	if !allowed {
+		t.Fatal("metric itself should still be allowed")
+	}
+	if _, ok := labels["request_id"]; ok {
+		t.Fatal("expected request_id label to be stripped")
+	}
+	if labels["cluster"] != "foo" {
+		t.Fatalf("expected cluster label to survive, got %v", labels)
+	}
+}
+
+func TestMatcherAllowModeKeepsOnlyMatchedLabels(t *testing.T) {
+	spec := &StatsMatcherSpec{
+		Mode:      MatcherAllow,
+		LabelKeys: []Pattern{{Kind: PatternGlob, Value: "cluster"}},
+	}
+	m := compileMatcher(spec)
+
+	allowed, labels := m.allow("upstream_rt", map[string]string{
+		"cluster":    "foo",
+		"request_id": "abc-123",
+	})
+	if !allowed {
+		t.Fatal("metric itself should still be allowed")
+	}
+	if len(labels) != 1 || labels["cluster"] != "foo" {
+		t.Fatalf("expected only cluster label to survive, got %v", labels)
+	}
+}
+
+func TestInvalidRegexMatchesNothing(t *testing.T) {
+	c := compilePattern(Pattern{Kind: PatternRegex, Value: "("})
+	if c.match("anything") {
+		t.Fatal("an invalid regex pattern must never match")
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// PrometheusConfig is the config to start a prometheus exposition endpoint.
+type PrometheusConfig struct {
+	// Enabled turns the prometheus endpoint on.
+	Enabled bool `json:"enabled,omitempty"`
+	// Port the endpoint listens on.
+	Port int `json:"port,omitempty"`
+	// Path is the HTTP path metrics are served on, defaults to "/metrics".
+	Path string `json:"path,omitempty"`
+}
+
+const defaultPrometheusPath = "/metrics"
+
+// promSink is a pull-based Sink: instead of periodically copying samples into
+// the prometheus registry, it keeps a set of CounterVec/GaugeVec and
+// refreshes them from defaultStore every time it is scraped.
+//
+// Vectors are cached by name *and* label key shape: two metrics instances
+// can share a (typ, key) pair while carrying different label sets (NewMetrics
+// takes an arbitrary map), and prometheus panics on WithLabelValues if the
+// argument count doesn't match the vec's declared label names.
+type promSink struct {
+	registry *prometheus.Registry
+
+	mutex    sync.Mutex
+	counters map[string]*prometheus.CounterVec
+	gauges   map[string]*prometheus.GaugeVec
+
+	// lastCounterValues holds, per counterSeriesKey, the cumulative Count()
+	// last seen for that series. A prometheus Counter only supports
+	// Add/Inc, so Report must add the delta since the last scrape rather
+	// than the gometrics Counter's lifetime total.
+	lastCounterValues map[string]int64
+}
+
+// NewPrometheusSink creates a Sink that serves metrics to a prometheus scraper.
+func NewPrometheusSink() Sink {
+	return &promSink{
+		registry:          prometheus.NewRegistry(),
+		counters:          make(map[string]*prometheus.CounterVec),
+		gauges:            make(map[string]*prometheus.GaugeVec),
+		lastCounterValues: make(map[string]int64),
+	}
+}
+
+// Report walks all metrics and updates the matching prometheus vectors so the
+// next scrape reflects the latest values.
+func (p *promSink) Report(all []types.Metrics) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, m := range all {
+		rawKeys, values := m.SortedLabels()
+		keys := sanitizeLabelNames(rawKeys)
+		m.Each(func(key string, i interface{}) {
+			name := metricName(m.Type(), key)
+			switch d := i.(type) {
+			case gometrics.Counter:
+				p.counterVec(name, keys).WithLabelValues(values...).Add(float64(p.counterDelta(name, keys, values, d.Count())))
+			case gometrics.Gauge:
+				p.gaugeVec(name, keys).WithLabelValues(values...).Set(float64(d.Value()))
+			case gometrics.Histogram:
+				pcts := d.Percentiles(HistogramPercentiles)
+				for i, pctName := range HistogramPercentileNames {
+					p.gaugeVec(name+"_"+pctName, keys).WithLabelValues(values...).Set(pcts[i])
+				}
+			}
+		})
+	}
+}
+
+// counterDelta returns how much the counter series identified by
+// name/keys/values has grown since the last call, and records cur as the new
+// baseline. If cur has gone backwards (e.g. the underlying counter was
+// reset) the whole of cur is reported rather than a negative delta.
+func (p *promSink) counterDelta(name string, keys, values []string, cur int64) int64 {
+	seriesKey := counterSeriesKey(name, keys, values)
+	delta := cur - p.lastCounterValues[seriesKey]
+	if delta < 0 {
+		delta = cur
+	}
+	p.lastCounterValues[seriesKey] = cur
+	return delta
+}
+
+// Flush is a no-op: promSink is scraped on demand by the prometheus HandlerFunc.
+func (p *promSink) Flush() error {
+	return nil
+}
+
+// vecCacheKey disambiguates vectors by both name and label key shape, since
+// two differently-labeled metrics instances can share a (typ, key) name.
+func vecCacheKey(name string, labelKeys []string) string {
+	return name + "|" + strings.Join(labelKeys, ",")
+}
+
+func (p *promSink) counterVec(name string, labelKeys []string) *prometheus.CounterVec {
+	cacheKey := vecCacheKey(name, labelKeys)
+	if vec, ok := p.counters[cacheKey]; ok {
+		return vec
+	}
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name}, labelKeys)
+	if err := p.registry.Register(vec); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			vec = are.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			// a differently-shaped vec already owns this name; log and keep
+			// using our own unregistered vec rather than crash the scrape.
+			log.DefaultLogger.Errorf("prometheus sink: could not register counter %s: %v", name, err)
+		}
+	}
+	p.counters[cacheKey] = vec
+	return vec
+}
+
+func (p *promSink) gaugeVec(name string, labelKeys []string) *prometheus.GaugeVec {
+	cacheKey := vecCacheKey(name, labelKeys)
+	if vec, ok := p.gauges[cacheKey]; ok {
+		return vec
+	}
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name}, labelKeys)
+	if err := p.registry.Register(vec); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			vec = are.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			log.DefaultLogger.Errorf("prometheus sink: could not register gauge %s: %v", name, err)
+		}
+	}
+	p.gauges[cacheKey] = vec
+	return vec
+}
+
+// handler returns the http.Handler that refreshes the vectors from
+// defaultStore and then delegates to the prometheus exposition format.
+func (p *promSink) handler() http.Handler {
+	inner := promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.Report(GetAll())
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// promInvalidChars matches any character not allowed in a prometheus metric
+// or label name ([a-zA-Z0-9_]).
+var promInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// sanitizePromName rewrites s into a valid prometheus name: invalid
+// characters become "_", and a leading digit is prefixed with "_" since
+// prometheus names must match [a-zA-Z_][a-zA-Z0-9_]*.
+func sanitizePromName(s string) string {
+	s = promInvalidChars.ReplaceAllString(s, "_")
+	if s == "" {
+		return "_"
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+func sanitizeLabelNames(keys []string) []string {
+	sanitized := make([]string, len(keys))
+	for i, k := range keys {
+		sanitized[i] = sanitizePromName(k)
+	}
+	return sanitized
+}
+
+func metricName(typ, key string) string {
+	return sanitizePromName(typ + "_" + key)
+}
+
+// StartPrometheusSink starts an HTTP server exposing the prometheus sink at
+// cfg.Path. Call this once, typically during mosn startup, when
+// cfg.Enabled is true.
+func StartPrometheusSink(cfg *PrometheusConfig) Sink {
+	path := cfg.Path
+	if path == "" {
+		path = defaultPrometheusPath
+	}
+
+	sink := NewPrometheusSink().(*promSink)
+
+	mux := http.NewServeMux()
+	mux.Handle(path, sink.handler())
+
+	server := &http.Server{
+		Addr:    portToAddr(cfg.Port),
+		Handler: mux,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.DefaultLogger.Errorf("prometheus sink server exited: %v", err)
+		}
+	}()
+
+	return sink
+}
+
+func portToAddr(port int) string {
+	if port <= 0 {
+		port = 34902
+	}
+	return ":" + strconv.Itoa(port)
+}
@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "testing"
+
+// TestPrometheusReportAddsCounterDeltaNotCumulativeTotal guards against
+// re-adding a gometrics Counter's lifetime total on every scrape: Report runs
+// on every scrape via handler(), and a prometheus Counter only supports
+// Add/Inc, so the sink must track what it has already reported and add only
+// the delta since then.
+func TestPrometheusReportAddsCounterDeltaNotCumulativeTotal(t *testing.T) {
+	ResetAll()
+	defer ResetAll()
+
+	m, err := NewMetrics("test_prom_counter", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	m.Counter("requests").Inc(10)
+
+	sink := NewPrometheusSink().(*promSink)
+	keys, values := m.SortedLabels()
+	name := metricName(m.Type(), "requests")
+	seriesKey := counterSeriesKey(name, sanitizeLabelNames(keys), values)
+
+	// counterValue reads the prometheus counter as a scraper would see it.
+	counterValue := func() float64 {
+		mfs, err := sink.registry.Gather()
+		if err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+		for _, mf := range mfs {
+			if mf.GetName() == name {
+				return mf.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		t.Fatalf("no gathered metric named %q", name)
+		return 0
+	}
+
+	sink.Report(GetAll())
+	if got := sink.lastCounterValues[seriesKey]; got != 10 {
+		t.Fatalf("expected last reported value 10 after first Report, got %d", got)
+	}
+	if got := counterValue(); got != 10 {
+		t.Fatalf("expected prometheus counter 10 after first Report, got %v", got)
+	}
+
+	// A second scrape with no further writes must not re-add the
+	// cumulative total again.
+	sink.Report(GetAll())
+	if got := sink.lastCounterValues[seriesKey]; got != 10 {
+		t.Fatalf("expected last reported value to remain 10 after second Report, got %d", got)
+	}
+	if got := counterValue(); got != 10 {
+		t.Fatalf("expected prometheus counter to remain 10 after second Report, got %v", got)
+	}
+
+	m.Counter("requests").Inc(5)
+	sink.Report(GetAll())
+	if got := sink.lastCounterValues[seriesKey]; got != 15 {
+		t.Fatalf("expected last reported value 15 after a further increment, got %d", got)
+	}
+	if got := counterValue(); got != 15 {
+		t.Fatalf("expected prometheus counter 15 after a further increment, got %v", got)
+	}
+}
+
+func TestCounterDeltaHandlesReset(t *testing.T) {
+	sink := NewPrometheusSink().(*promSink)
+
+	if got := sink.counterDelta("c", nil, nil, 10); got != 10 {
+		t.Fatalf("expected first delta to equal the initial value, got %d", got)
+	}
+	if got := sink.counterDelta("c", nil, nil, 12); got != 2 {
+		t.Fatalf("expected delta of 2, got %d", got)
+	}
+	// a smaller cumulative value than last time means the underlying
+	// counter was reset; report it as an absolute value, not a negative delta.
+	if got := sink.counterDelta("c", nil, nil, 3); got != 3 {
+		t.Fatalf("expected delta 3 on reset, got %d", got)
+	}
+}
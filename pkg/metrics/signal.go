@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// inmemSignal dumps a human readable snapshot of defaultStore to w whenever
+// sig is received, so operators can grab a point-in-time metrics view from a
+// running process without a scrape target or admin API.
+type inmemSignal struct {
+	ch   chan os.Signal
+	w    io.Writer
+	stop chan struct{}
+}
+
+var (
+	signalMutex  sync.Mutex
+	activeSignal *inmemSignal
+)
+
+// EnableSignalDump starts watching sig and writes a metrics snapshot to w
+// every time it fires. sig defaults to SIGUSR1 when nil, w defaults to
+// os.Stderr when nil. Calling it again replaces the previously registered
+// signal/writer.
+func EnableSignalDump(sig os.Signal, w io.Writer) {
+	if sig == nil {
+		sig = syscall.SIGUSR1
+	}
+	if w == nil {
+		w = os.Stderr
+	}
+
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+
+	if activeSignal != nil {
+		signal.Stop(activeSignal.ch)
+		close(activeSignal.stop)
+	}
+
+	s := &inmemSignal{
+		ch:   make(chan os.Signal, 1),
+		w:    w,
+		stop: make(chan struct{}),
+	}
+	signal.Notify(s.ch, sig)
+	activeSignal = s
+
+	go s.loop()
+}
+
+func (s *inmemSignal) loop() {
+	for {
+		select {
+		case <-s.ch:
+			dumpAll(s.w)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// dumpAll writes every counter, gauge and histogram currently registered in
+// defaultStore to w in a greppable "type.key{labels}: ..." format.
+func dumpAll(w io.Writer) {
+	for _, m := range GetAll() {
+		keys, values := m.SortedLabels()
+		labelStr := formatLabels(keys, values)
+		m.Each(func(key string, i interface{}) {
+			switch d := i.(type) {
+			case gometrics.Counter:
+				fmt.Fprintf(w, "%s.%s%s: count=%d\n", m.Type(), key, labelStr, d.Count())
+			case gometrics.Gauge:
+				fmt.Fprintf(w, "%s.%s%s: value=%d\n", m.Type(), key, labelStr, d.Value())
+			case gometrics.Histogram:
+				ps := d.Percentiles(HistogramPercentiles)
+				fmt.Fprintf(w, "%s.%s%s: count=%d min=%d max=%d mean=%.2f p50=%.2f p90=%.2f p99=%.2f p999=%.2f\n",
+					m.Type(), key, labelStr, d.Count(), d.Min(), d.Max(), d.Mean(), ps[0], ps[1], ps[2], ps[3])
+			}
+		})
+	}
+}
+
+func formatLabels(keys, values []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(values[i])
+	}
+	b.WriteString("}")
+	return b.String()
+}
@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpAllFormatsCounterGaugeAndHistogram(t *testing.T) {
+	ResetAll()
+	defer ResetAll()
+
+	m, err := NewMetrics("test_dump", map[string]string{"cluster": "foo"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	m.Counter("requests").Inc(3)
+	m.Gauge("connections").Update(7)
+	m.Histogram("latency").Update(10)
+
+	var buf bytes.Buffer
+	dumpAll(&buf)
+	out := buf.String()
+
+	if !strings.Contains(out, "test_dump.requests{cluster=foo}: count=3") {
+		t.Fatalf("expected counter line, got %q", out)
+	}
+	if !strings.Contains(out, "test_dump.connections{cluster=foo}: value=7") {
+		t.Fatalf("expected gauge line, got %q", out)
+	}
+	if !strings.Contains(out, "test_dump.latency{cluster=foo}: count=1") {
+		t.Fatalf("expected histogram line, got %q", out)
+	}
+}
+
+func TestFormatLabelsEmpty(t *testing.T) {
+	if got := formatLabels(nil, nil); got != "" {
+		t.Fatalf("expected empty labels to format as \"\", got %q", got)
+	}
+}
+
+func TestFormatLabelsJoinsKeyValuePairs(t *testing.T) {
+	got := formatLabels([]string{"a", "b"}, []string{"1", "2"})
+	want := "{a=1,b=2}"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
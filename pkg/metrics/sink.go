@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+)
+
+// Sink is the interface implemented by metrics exporters (prometheus, statsd, ...).
+// A Sink receives a point-in-time snapshot of every types.Metrics registered in
+// defaultStore and is responsible for translating/delivering it to an external system.
+type Sink interface {
+	// Report is invoked with the current snapshot of all registered metrics. Sinks
+	// should read labels through m.SortedLabels() so the emitted output is stable
+	// and deterministic regardless of map iteration order.
+	Report(all []types.Metrics)
+
+	// Flush forces any data buffered by Report to be written out or sent now.
+	// Pull-based sinks that do not buffer (e.g. prometheus, which is scraped on
+	// demand) may treat this as a no-op.
+	Flush() error
+}
+
+var (
+	sinksMutex sync.Mutex
+	sinks      []Sink
+
+	reportLoopMutex sync.Mutex
+	reportLoopStop  chan struct{}
+)
+
+// RegisterSink registers a Sink to receive a periodic snapshot of all
+// metrics, pushed by the report loop started with StartReportLoop. Push-style
+// sinks (e.g. statsd) should be registered this way; pull-style sinks (e.g.
+// prometheus) that are driven by an external scraper do not need to.
+func RegisterSink(sink Sink) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	sinks = append(sinks, sink)
+}
+
+// StartReportLoop starts a background goroutine that, every interval, reports
+// the current metrics snapshot to every registered sink and flushes it.
+// Calling it again restarts the loop with the new interval.
+func StartReportLoop(interval time.Duration) {
+	reportLoopMutex.Lock()
+	defer reportLoopMutex.Unlock()
+
+	if reportLoopStop != nil {
+		close(reportLoopStop)
+	}
+	stop := make(chan struct{})
+	reportLoopStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				reportOnce()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopReportLoop stops a report loop previously started with StartReportLoop.
+// It is a no-op if no loop is running.
+func StopReportLoop() {
+	reportLoopMutex.Lock()
+	defer reportLoopMutex.Unlock()
+
+	if reportLoopStop != nil {
+		close(reportLoopStop)
+		reportLoopStop = nil
+	}
+}
+
+// counterSeriesKey identifies a single counter series by name and label
+// values, so push/pull sinks can track the last cumulative value they
+// reported per series and emit only the delta since then. Label keys are
+// included alongside values since two differently-shaped label sets could
+// otherwise collide on their values alone.
+func counterSeriesKey(name string, keys, values []string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for i, k := range keys {
+		b.WriteByte('|')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(values[i])
+	}
+	return b.String()
+}
+
+func reportOnce() {
+	sinksMutex.Lock()
+	snapshot := make([]Sink, len(sinks))
+	copy(snapshot, sinks)
+	sinksMutex.Unlock()
+
+	if len(snapshot) == 0 {
+		return
+	}
+
+	all := GetAll()
+	for _, sink := range snapshot {
+		sink.Report(all)
+		if err := sink.Flush(); err != nil {
+			log.DefaultLogger.Errorf("metrics sink flush failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alipay/sofa-mosn/pkg/log"
+	"github.com/alipay/sofa-mosn/pkg/types"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// StatsDConfig is the config to create a StatsDSink.
+type StatsDConfig struct {
+	// Addr is the statsd/dogstatsd server address, e.g. "127.0.0.1:8125".
+	Addr string `json:"addr,omitempty"`
+	// DogStatsD switches the wire format from plain StatsD to the
+	// DogStatsD/InfluxDB-extended format, emitting labels as tags instead of
+	// flattening them into the metric name.
+	DogStatsD bool `json:"dogstatsd,omitempty"`
+	// MaxPacketSize bounds the size of a single UDP datagram, to respect MTU.
+	// Defaults to 1432 bytes, which fits a standard 1500-byte Ethernet MTU.
+	MaxPacketSize int `json:"max_packet_size,omitempty"`
+	// BufferSize is the number of lines buffered between flushes before the
+	// oldest ones are dropped.
+	BufferSize int `json:"buffer_size,omitempty"`
+}
+
+const (
+	defaultMaxPacketSize = 1432
+	defaultBufferSize    = 4096
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// StatsDSink is a Sink that also supports graceful shutdown of its UDP
+// connection.
+type StatsDSink interface {
+	Sink
+	Close() error
+}
+
+// statsdSink is a push-based Sink that drains counters/gauges/histograms from
+// defaultStore and ships them to a statsd or dogstatsd server over UDP.
+type statsdSink struct {
+	addr          string
+	dogStatsD     bool
+	maxPacketSize int
+
+	mutex       sync.Mutex
+	conn        net.Conn
+	backoff     time.Duration
+	nextAttempt time.Time
+	lines       *ringBuffer
+
+	// lastCounterValues holds, per counterSeriesKey, the cumulative Count()
+	// last sent for that series. StatsD's "c" type means "add this much to
+	// the bucket for this flush interval", so Report must send the delta
+	// since the last flush rather than the gometrics Counter's lifetime total.
+	lastCounterValues map[string]int64
+}
+
+// NewStatsDSink dials addr (without blocking on connection) and returns a
+// Sink ready to be passed to RegisterSink.
+func NewStatsDSink(cfg StatsDConfig) (StatsDSink, error) {
+	maxPacketSize := cfg.MaxPacketSize
+	if maxPacketSize <= 0 {
+		maxPacketSize = defaultMaxPacketSize
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
+	s := &statsdSink{
+		addr:              cfg.Addr,
+		dogStatsD:         cfg.DogStatsD,
+		maxPacketSize:     maxPacketSize,
+		lines:             newRingBuffer(bufferSize),
+		lastCounterValues: make(map[string]int64),
+	}
+	s.dial()
+	return s, nil
+}
+
+func (s *statsdSink) dial() {
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		log.DefaultLogger.Errorf("statsd sink: dial %s failed: %v", s.addr, err)
+		s.noteDialFailure()
+		return
+	}
+	s.conn = conn
+}
+
+// noteDialFailure escalates the backoff and records when the next reconnect
+// attempt is allowed.
+func (s *statsdSink) noteDialFailure() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	backoff := s.backoff
+	if backoff == 0 {
+		backoff = minBackoff
+	}
+	s.backoff = nextBackoff(backoff)
+	s.nextAttempt = time.Now().Add(s.backoff)
+}
+
+// Report translates every counter/gauge/histogram into a statsd line and
+// buffers it for the next Flush.
+func (s *statsdSink) Report(all []types.Metrics) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, m := range all {
+		keys, values := m.SortedLabels()
+		m.Each(func(key string, i interface{}) {
+			name := fullMetricName(m.Type(), key)
+			switch d := i.(type) {
+			case gometrics.Counter:
+				delta := s.counterDelta(name, keys, values, d.Count())
+				s.buffer(s.format(name, "c", fmt.Sprintf("%d", delta), keys, values))
+			case gometrics.Gauge:
+				s.buffer(s.format(name, "g", fmt.Sprintf("%d", d.Value()), keys, values))
+			case gometrics.Histogram:
+				pcts := d.Percentiles(HistogramPercentiles)
+				for i, pctName := range HistogramPercentileNames {
+					s.buffer(s.format(name+"."+pctName, "g", fmt.Sprintf("%d", int64(pcts[i])), keys, values))
+				}
+			}
+		})
+	}
+}
+
+// counterDelta returns how much the counter series identified by
+// name/keys/values has grown since the last report, and records cur as the
+// new baseline. Callers must hold s.mutex. If cur has gone backwards (e.g.
+// the underlying counter was reset) the whole of cur is reported rather than
+// a negative delta.
+func (s *statsdSink) counterDelta(name string, keys, values []string, cur int64) int64 {
+	seriesKey := counterSeriesKey(name, keys, values)
+	delta := cur - s.lastCounterValues[seriesKey]
+	if delta < 0 {
+		delta = cur
+	}
+	s.lastCounterValues[seriesKey] = cur
+	return delta
+}
+
+func (s *statsdSink) buffer(line string) {
+	s.lines.push(line)
+}
+
+// ringBuffer is a fixed-capacity, preallocated queue of lines: once full, a
+// push overwrites the oldest entry instead of growing, so memory stays
+// bounded at exactly its configured size under sustained send failures.
+type ringBuffer struct {
+	buf   []string
+	head  int
+	count int
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]string, size)}
+}
+
+func (r *ringBuffer) push(line string) {
+	if len(r.buf) == 0 {
+		return
+	}
+	idx := (r.head + r.count) % len(r.buf)
+	r.buf[idx] = line
+	if r.count < len(r.buf) {
+		r.count++
+	} else {
+		r.head = (r.head + 1) % len(r.buf)
+	}
+}
+
+// drain returns the buffered lines in push order and empties the buffer.
+func (r *ringBuffer) drain() []string {
+	out := make([]string, r.count)
+	for i := 0; i < r.count; i++ {
+		out[i] = r.buf[(r.head+i)%len(r.buf)]
+	}
+	r.head, r.count = 0, 0
+	return out
+}
+
+// format renders a single metric as either plain StatsD ("name:value|type")
+// or DogStatsD ("name:value|type|#k:v,k:v") depending on s.dogStatsD.
+func (s *statsdSink) format(name, typ, value string, labelKeys, labelVals []string) string {
+	if !s.dogStatsD {
+		full := name
+		for i, k := range labelKeys {
+			full = full + "." + k + "." + labelVals[i]
+		}
+		return fmt.Sprintf("%s:%s|%s", full, value, typ)
+	}
+
+	if len(labelKeys) == 0 {
+		return fmt.Sprintf("%s:%s|%s", name, value, typ)
+	}
+	tags := make([]string, len(labelKeys))
+	for i, k := range labelKeys {
+		tags[i] = k + ":" + labelVals[i]
+	}
+	return fmt.Sprintf("%s:%s|%s|#%s", name, value, typ, strings.Join(tags, ","))
+}
+
+// Flush packs the buffered lines into UDP datagrams no larger than
+// maxPacketSize and sends them, reconnecting with backoff on failure. A
+// single line longer than maxPacketSize is sent alone in its own, larger
+// datagram; lines are never split.
+func (s *statsdSink) Flush() error {
+	s.mutex.Lock()
+	lines := s.lines.drain()
+	s.mutex.Unlock()
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var packet bytes.Buffer
+	var lastErr error
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > s.maxPacketSize {
+			if err := s.send(packet.Bytes()); err != nil {
+				lastErr = err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if err := s.send(packet.Bytes()); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (s *statsdSink) send(b []byte) error {
+	s.mutex.Lock()
+	conn := s.conn
+	s.mutex.Unlock()
+
+	if conn == nil {
+		return s.reconnect(b)
+	}
+
+	if _, err := conn.Write(b); err != nil {
+		return s.reconnect(b)
+	}
+	s.resetBackoff()
+	return nil
+}
+
+// reconnect re-dials the statsd server, backing off exponentially between
+// attempts so a persistently unreachable server does not spin the sender. If
+// the previous failure's backoff window has not elapsed yet, it fails fast
+// without touching the network.
+func (s *statsdSink) reconnect(b []byte) error {
+	s.mutex.Lock()
+	if until := s.nextAttempt; !until.IsZero() && time.Now().Before(until) {
+		s.mutex.Unlock()
+		return fmt.Errorf("statsd sink: backing off reconnect to %s until %s", s.addr, until)
+	}
+	s.mutex.Unlock()
+
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		s.noteDialFailure()
+		return fmt.Errorf("statsd sink: reconnect to %s failed: %v", s.addr, err)
+	}
+
+	s.mutex.Lock()
+	s.conn = conn
+	s.mutex.Unlock()
+	s.resetBackoff()
+
+	_, err = conn.Write(b)
+	return err
+}
+
+func (s *statsdSink) resetBackoff() {
+	s.mutex.Lock()
+	s.backoff = 0
+	s.nextAttempt = time.Time{}
+	s.mutex.Unlock()
+}
+
+func nextBackoff(cur time.Duration) time.Duration {
+	next := cur * 2
+	if next > maxBackoff {
+		next = maxBackoff
+	}
+	return next
+}
+
+// Close flushes any buffered metrics and releases the UDP connection.
+func (s *statsdSink) Close() error {
+	err := s.Flush()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		if cerr := s.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		s.conn = nil
+	}
+	return err
+}
+
+func fullMetricName(typ, key string) string {
+	return typ + "." + key
+}
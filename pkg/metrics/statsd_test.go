@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestStatsdSink() *statsdSink {
+	return &statsdSink{
+		maxPacketSize:     defaultMaxPacketSize,
+		lines:             newRingBuffer(defaultBufferSize),
+		lastCounterValues: make(map[string]int64),
+	}
+}
+
+// TestStatsdReportSendsCounterDeltaNotCumulativeTotal guards against
+// re-sending a gometrics Counter's lifetime total as a StatsD "c" value on
+// every flush tick: "c" means "add this much for this interval", so Report
+// must send only the delta since the last report.
+func TestStatsdReportSendsCounterDeltaNotCumulativeTotal(t *testing.T) {
+	ResetAll()
+	defer ResetAll()
+
+	m, err := NewMetrics("test_statsd_counter", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	m.Counter("requests").Inc(10)
+
+	s := newTestStatsdSink()
+
+	s.Report(GetAll())
+	if got := lastBufferedLine(t, s, "test_statsd_counter.requests"); got != "test_statsd_counter.requests.id.1:10|c" {
+		t.Fatalf("unexpected first report line: %q", got)
+	}
+
+	// A second report with no further writes must send a delta of 0, not
+	// the cumulative total again.
+	s.Report(GetAll())
+	if got := lastBufferedLine(t, s, "test_statsd_counter.requests"); got != "test_statsd_counter.requests.id.1:0|c" {
+		t.Fatalf("unexpected second report line: %q", got)
+	}
+
+	m.Counter("requests").Inc(5)
+	s.Report(GetAll())
+	if got := lastBufferedLine(t, s, "test_statsd_counter.requests"); got != "test_statsd_counter.requests.id.1:5|c" {
+		t.Fatalf("unexpected third report line: %q", got)
+	}
+}
+
+func lastBufferedLine(t *testing.T, s *statsdSink, prefix string) string {
+	t.Helper()
+	s.mutex.Lock()
+	lines := s.lines.drain()
+	for _, l := range lines {
+		s.lines.push(l)
+	}
+	s.mutex.Unlock()
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], prefix) {
+			return lines[i]
+		}
+	}
+	t.Fatalf("no buffered line found with prefix %q", prefix)
+	return ""
+}
+
+// TestRingBufferBoundsMemoryAtConfiguredSize guards against the old
+// slice-reslicing "drop oldest" implementation, where dropping the first
+// element shrank cap() by one and the next append regrew it past the
+// configured size. A ring buffer must stay at exactly its preallocated
+// capacity no matter how many lines are pushed.
+func TestRingBufferBoundsMemoryAtConfiguredSize(t *testing.T) {
+	const size = 4
+	r := newRingBuffer(size)
+
+	for i := 0; i < size*10; i++ {
+		r.push("line")
+	}
+
+	if got := cap(r.buf); got != size {
+		t.Fatalf("expected ring buffer capacity to stay at %d, got %d", size, got)
+	}
+	if got := r.count; got != size {
+		t.Fatalf("expected ring buffer to hold %d lines, got %d", size, got)
+	}
+}
+
+func TestRingBufferDrainReturnsInPushOrder(t *testing.T) {
+	r := newRingBuffer(3)
+	r.push("a")
+	r.push("b")
+	r.push("c")
+	r.push("d") // overwrites "a"
+
+	got := r.drain()
+	want := []string{"b", "c", "d"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	if got := r.drain(); len(got) != 0 {
+		t.Fatalf("expected drain to empty the buffer, got %v", got)
+	}
+}
@@ -20,6 +20,8 @@ package metrics
 import (
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"fmt"
 	"sort"
@@ -38,8 +40,12 @@ var (
 
 // stats memory store
 type store struct {
-	rejectAll       bool
+	rejectAll bool
+	// exclusionLabels is the legacy SetStatsMatcher exclusion list: a metric
+	// carrying any of these label keys is dropped entirely, independent of
+	// the newer matcher below.
 	exclusionLabels []string
+	matcher         *compiledMatcher
 	metrics         map[string]types.Metrics
 	mutex           sync.RWMutex
 }
@@ -52,6 +58,14 @@ type metrics struct {
 	labelVals []string
 
 	registry gometrics.Registry
+
+	// lastAccess is a UnixNano timestamp updated on every Counter/Gauge/
+	// Histogram access, read by the cardinality sweeper to find idle entries.
+	lastAccess int64
+}
+
+func (s *metrics) touch() {
+	atomic.StoreInt64(&s.lastAccess, time.Now().UnixNano())
 }
 
 func init() {
@@ -61,8 +75,11 @@ func init() {
 	}
 }
 
-// SetStatsMatcher sets the exclusion labels
-// if a metrics labels contains in exclusions, it will be ignored
+// SetStatsMatcher sets the exclusion labels.
+// if a metrics labels contains in exclusions, it will be ignored entirely --
+// the whole metric is dropped, not just the matching labels. Use
+// SetStatsMatcherSpec for glob/regex patterns, allow-list mode, or to strip
+// individual labels while keeping the metric.
 func SetStatsMatcher(all bool, exclusions []string) {
 	defaultStore.mutex.Lock()
 	defer defaultStore.mutex.Unlock()
@@ -72,20 +89,42 @@ func SetStatsMatcher(all bool, exclusions []string) {
 	defaultStore.exclusionLabels = exclusions
 }
 
-// isExclusion returns the labels will be ignored or not
-func isExclusion(labels map[string]string) bool {
+// SetStatsMatcherSpec sets the active stats matcher. When all is true every
+// metrics is rejected regardless of spec. spec may be nil to disable pattern
+// based filtering entirely. This is independent of SetStatsMatcher's
+// exclusionLabels, which continue to reject whole metrics.
+func SetStatsMatcherSpec(all bool, spec *StatsMatcherSpec) {
+	defaultStore.mutex.Lock()
+	defer defaultStore.mutex.Unlock()
+	if all {
+		defaultStore.rejectAll = true
+	}
+	defaultStore.matcher = compileMatcher(spec)
+}
+
+// allowMetric reports whether a (typ, labels) pair should be recorded, and
+// returns the label map that should actually be attached to it -- a metric
+// can be kept while specific labels (e.g. a high-cardinality request_id) are
+// stripped from it.
+func allowMetric(typ string, labels map[string]string) (bool, map[string]string) {
 	defaultStore.mutex.RLock()
-	defer defaultStore.mutex.RUnlock()
-	if defaultStore.rejectAll {
-		return true
+	rejectAll := defaultStore.rejectAll
+	exclusionLabels := defaultStore.exclusionLabels
+	matcher := defaultStore.matcher
+	defaultStore.mutex.RUnlock()
+
+	if rejectAll {
+		return false, nil
 	}
-	// TODO: support pattern
-	for _, label := range defaultStore.exclusionLabels {
+	for _, label := range exclusionLabels {
 		if _, ok := labels[label]; ok {
-			return true
+			return false, nil
 		}
 	}
-	return false
+	if matcher == nil {
+		return true, labels
+	}
+	return matcher.allow(typ, labels)
 }
 
 // NewMetrics returns a metrics
@@ -94,10 +133,11 @@ func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
 	if len(labels) > maxLabelCount {
 		return nil, errLabelCountExceeded
 	}
-	// support exclusion only
-	if isExclusion(labels) {
+	allowed, filtered := allowMetric(typ, labels)
+	if !allowed {
 		return NewNilMetrics(typ, labels)
 	}
+	labels = filtered
 
 	defaultStore.mutex.Lock()
 	defer defaultStore.mutex.Unlock()
@@ -113,6 +153,7 @@ func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
 		labels:   labels,
 		registry: gometrics.NewRegistry(),
 	}
+	stats.touch()
 
 	defaultStore.metrics[name] = stats
 
@@ -152,15 +193,20 @@ func (s *metrics) SortedLabels() (keys, values []string) {
 }
 
 func (s *metrics) Counter(key string) gometrics.Counter {
+	s.touch()
 	return s.registry.GetOrRegister(key, gometrics.NewCounter).(gometrics.Counter)
 }
 
 func (s *metrics) Gauge(key string) gometrics.Gauge {
+	s.touch()
 	return s.registry.GetOrRegister(key, gometrics.NewGauge).(gometrics.Gauge)
 }
 
 func (s *metrics) Histogram(key string) gometrics.Histogram {
-	return s.registry.GetOrRegister(key, func() gometrics.Histogram { return gometrics.NewHistogram(gometrics.NewUniformSample(100)) }).(gometrics.Histogram)
+	s.touch()
+	return s.registry.GetOrRegister(key, func() gometrics.Histogram {
+		return gometrics.NewHistogram(newSample(histogramConfigFor(s.typ).Kind))
+	}).(gometrics.Histogram)
 }
 
 func (s *metrics) Each(f func(string, interface{})) {
@@ -193,6 +239,7 @@ func ResetAll() {
 	defaultStore.metrics = make(map[string]types.Metrics, 100)
 	defaultStore.rejectAll = false
 	defaultStore.exclusionLabels = nil
+	defaultStore.matcher = nil
 }
 
 func mapEqual(x, y map[string]string) bool {
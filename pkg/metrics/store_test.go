@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "testing"
+
+// TestSetStatsMatcherRejectsWholeMetric guards the legacy SetStatsMatcher
+// semantics: a label key listed in exclusions must drop the whole metric,
+// not just that one label (SetStatsMatcherSpec's LabelKeys is the place for
+// stripping individual labels while keeping the metric).
+func TestSetStatsMatcherRejectsWholeMetric(t *testing.T) {
+	ResetAll()
+	defer ResetAll()
+
+	SetStatsMatcher(false, []string{"request_id"})
+
+	m, err := NewMetrics("test_exclusion", map[string]string{
+		"cluster":    "foo",
+		"request_id": "abc-123",
+	})
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	if _, ok := m.(*metrics); ok {
+		t.Fatal("expected a metric carrying an excluded label to be replaced with a nil metric")
+	}
+
+	allowed, ok := NewMetrics("test_exclusion_clean", map[string]string{"cluster": "foo"})
+	if ok != nil {
+		t.Fatalf("NewMetrics: %v", ok)
+	}
+	if _, isReal := allowed.(*metrics); !isReal {
+		t.Fatal("expected a metric without excluded labels to be recorded")
+	}
+}
+
+func TestSetStatsMatcherRejectAll(t *testing.T) {
+	ResetAll()
+	defer ResetAll()
+
+	SetStatsMatcher(true, nil)
+
+	m, err := NewMetrics("test_reject_all", nil)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	if _, ok := m.(*metrics); ok {
+		t.Fatal("expected every metric to be rejected when all is true")
+	}
+}